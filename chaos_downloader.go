@@ -1,26 +1,57 @@
 // Package main implements a tool for downloading and organizing security data from Project Discovery's Chaos dataset.
-// The tool fetches a list of targets from a JSON index, downloads ZIP archives associated with each target,
-// extracts them into dedicated directories, and compiles all text file contents into a single file named 'everything.txt'.
-// This file, 'everything.txt', is saved in the directory from which the script is executed, providing a consolidated
-// view of the textual data gathered from the downloaded archives.
+// The tool fetches a list of targets from a JSON index, downloads the archive associated with each target
+// (ZIP, tar, tar.gz, or tar.bz2), extracts them into dedicated directories, and compiles the non-empty lines of all
+// text files, with duplicates dropped, into a file named 'everything.txt' (or, with -compress, 'everything.txt.gz',
+// and with -shard, one file per shard). The output is saved in the directory from which the script is executed,
+// providing a consolidated view of the textual data gathered from the downloaded archives.
 package main
 
 import (
+	"archive/tar"
 	"archive/zip"
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/tonytsep/chaos_downloader/pkg/chaos"
 )
 
+// cacheDirName holds resumable partial downloads across interrupted runs.
+const cacheDirName = ".cache"
+
+// maxDownloadAttempts bounds how many times a single entry's download is retried after a
+// network error or 5xx response before it's given up on.
+const maxDownloadAttempts = 5
+
 // main orchestrates the script's workflow, including creating the base directory, processing the JSON index,
 // downloading and extracting data, and finally, compiling the 'everything.txt' file.
 func main() {
+	concurrency := flag.Int("concurrency", runtime.NumCPU(), "number of concurrent downloads")
+	compress := flag.Bool("compress", false, "gzip-compress the everything.txt output")
+	shardBy := flag.String("shard", "", `shard the everything.txt output, either by "tld" or by "size"`)
+	shardSize := flag.Int64("shard-size", 256<<20, `maximum bytes per shard when -shard="size"`)
+	flag.Parse()
+
 	// Specify the directory where all operations will take place.
 	baseDir := filepath.Join(".", "AllChaosData")
 
@@ -32,123 +63,669 @@ func main() {
 	// Define the JSON index URL.
 	jsonURL := "https://chaos-data.projectdiscovery.io/index.json"
 
-	// Process each entry in the JSON index.
-	if err := processURLs(jsonURL, baseDir); err != nil {
-		log.Fatalf("Failed to process URLs: %v", err)
+	concatOpts := ConcatenatorOptions{Compress: *compress, ShardSizeBytes: *shardSize}
+	switch *shardBy {
+	case "":
+		concatOpts.Shard = ShardNone
+	case "tld":
+		concatOpts.Shard = ShardByTLD
+	case "size":
+		concatOpts.Shard = ShardBySize
+	default:
+		log.Fatalf(`invalid -shard value %q (want "tld", "size", or "")`, *shardBy)
+	}
+
+	// Compile all .txt files into 'everything.txt', located in the script's execution directory,
+	// incrementally as each target finishes extracting.
+	concat, err := NewConcatenator(".", concatOpts)
+	if err != nil {
+		log.Fatalf("Failed to set up output concatenation: %v", err)
+	}
+
+	// Cancel in-flight downloads on SIGINT. Partial ZIP downloads stay in the cache directory so
+	// the next run can resume them.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Process each entry in the JSON index. A canceled run (Ctrl-C) still falls through to
+	// concat.Close below so everything.txt is flushed instead of left truncated, or, with
+	// -compress, missing its gzip trailer entirely.
+	err = processEntries(ctx, jsonURL, baseDir, *concurrency, chaos.DefaultExtractOptions(), concat)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatalf("Failed to process entries: %v", err)
 	}
 
-	// Compile all .txt files into 'everything.txt', located in the script's execution directory.
-	if err := concatenateAllTxtFiles(baseDir, "."); err != nil {
-		log.Fatalf("Failed to concatenate all txt files into everything.txt: %v", err)
+	if closeErr := concat.Close(); closeErr != nil {
+		log.Fatalf("Failed to finalize everything.txt: %v", closeErr)
+	}
+	if err != nil {
+		log.Printf("Canceled: %v", err)
 	}
 }
 
-// processURLs fetches the JSON index from the provided URL and processes each entry by downloading
-// the associated ZIP file, extracting its contents, and organizing them into directories named after each entry.
-func processURLs(jsonURL, baseDir string) error {
-	// Fetch the JSON index.
-	resp, err := http.Get(jsonURL)
+// processEntries fetches the JSON index and downloads+extracts every entry using a bounded pool
+// of concurrency workers, reporting progress as it goes. It stops feeding new work once ctx is
+// canceled, but lets in-flight entries finish or fail on their own.
+func processEntries(ctx context.Context, jsonURL, baseDir string, concurrency int, opts chaos.ExtractOptions, concat *Concatenator) error {
+	index, err := (&chaos.Client{IndexURL: jsonURL}).FetchIndex(ctx)
 	if err != nil {
-		return fmt.Errorf("error fetching JSON index: %w", err)
+		return err
+	}
+	entries := index.Entries
+	if concurrency < 1 {
+		concurrency = 1
 	}
-	defer resp.Body.Close()
 
-	// Decode the JSON index into a slice of entries.
-	var entries []struct {
-		Name string `json:"name"`
-		URL  string `json:"URL"`
+	manifestPath := filepath.Join(baseDir, "manifest.json")
+	mf, err := loadManifest(manifestPath)
+	if err != nil {
+		return err
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
-		return fmt.Errorf("error decoding JSON index: %w", err)
+
+	jobs := make(chan chaos.Entry)
+	reporter := newProgressReporter(len(entries))
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range jobs {
+				if err := processEntry(ctx, entry, baseDir, opts, reporter, mf, concat); err != nil {
+					log.Printf("Failed to process %s: %v", entry.Name, err)
+				}
+			}
+		}()
 	}
 
-	// Process each entry in the index.
+feed:
 	for _, entry := range entries {
-		fmt.Printf("Processing %s...\n", entry.Name)
-		if err := downloadAndUnzip(entry.URL, entry.Name, baseDir); err != nil {
-			log.Printf("Failed to process %s: %v\n", entry.Name, err)
+		select {
+		case jobs <- entry:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := mf.save(); err != nil {
+		log.Printf("Failed to save manifest %s: %v", manifestPath, err)
+	}
+
+	return ctx.Err()
+}
+
+// processEntry downloads the archive for entry and extracts it into a directory within baseDir
+// named after the entry. Archives served as ZIP (Chaos's native format) are staged in a cache
+// directory so an interrupted download can resume via an HTTP Range request on the next run;
+// tar-family archives are streamed straight into their Extractor and simply retried from scratch
+// on failure, since they don't need random access.
+//
+// Before downloading anything, entry's prior manifest record (if any) is used to issue a
+// conditional request; a 304 response means the archive is unchanged, and extraction is skipped
+// entirely as long as dirPath's contents still hash to the manifest's recorded DirHash. If the
+// server doesn't honor conditional requests, a ZIP archive is still downloaded but its SHA-256 is
+// compared against the manifest, and re-extraction is skipped under that same DirHash check. Both
+// paths guard against a cache file or manifest record surviving the loss of its extracted
+// directory. Tar-family archives have no such hash check: once downloaded they are always
+// re-extracted, and their SHA-256 is only recorded in the manifest.
+func processEntry(ctx context.Context, entry chaos.Entry, baseDir string, opts chaos.ExtractOptions, reporter *progressReporter, mf *manifest, concat *Concatenator) error {
+	dirPath := filepath.Join(baseDir, entry.Name)
+	if err := os.MkdirAll(dirPath, os.ModePerm); err != nil {
+		return fmt.Errorf("error creating directory %s: %w", dirPath, err)
+	}
+
+	prior, hadPrior := mf.get(entry.Name)
+	if hadPrior {
+		notModified, err := isNotModified(ctx, entry.URL, prior)
+		if err != nil {
+			log.Printf("conditional check for %s failed, downloading anyway: %v", entry.Name, err)
+		} else if notModified {
+			if dirMatchesManifest(dirPath, prior) {
+				return finishEntry(dirPath, entry.Name, concat, reporter)
+			}
+			log.Printf("%s: extracted directory doesn't match the manifest despite a 304 response; re-downloading", entry.Name)
 		}
 	}
+
+	if looksLikeZip(entry.URL) {
+		cachePath := filepath.Join(baseDir, cacheDirName, entry.Name+".zip")
+		if err := os.MkdirAll(filepath.Dir(cachePath), os.ModePerm); err != nil {
+			return fmt.Errorf("error creating cache directory: %w", err)
+		}
+		validators, err := downloadToCache(ctx, entry.URL, cachePath, entry.Name, reporter)
+		if err != nil {
+			return fmt.Errorf("error downloading %s: %w", entry.URL, err)
+		}
+
+		sum, err := sha256File(cachePath)
+		if err != nil {
+			return fmt.Errorf("error hashing %s: %w", cachePath, err)
+		}
+
+		if hadPrior && prior.ArchiveSHA256 == sum {
+			if dirMatchesManifest(dirPath, prior) {
+				mf.set(entry.Name, manifestEntry{URL: entry.URL, ETag: validators.etag, LastModified: validators.lastModified, ArchiveSHA256: sum, DirHash: prior.DirHash})
+				return finishEntry(dirPath, entry.Name, concat, reporter)
+			}
+			log.Printf("%s: extracted directory doesn't match the manifest despite a matching archive hash; re-extracting", entry.Name)
+		}
+
+		if err := extractZipFile(cachePath, dirPath, opts); err != nil {
+			return fmt.Errorf("error extracting %s: %w", entry.URL, err)
+		}
+
+		dirHash, err := hashDir(dirPath)
+		if err != nil {
+			return fmt.Errorf("error hashing extracted directory %s: %w", dirPath, err)
+		}
+
+		mf.set(entry.Name, manifestEntry{URL: entry.URL, ETag: validators.etag, LastModified: validators.lastModified, ArchiveSHA256: sum, DirHash: dirHash})
+		return finishEntry(dirPath, entry.Name, concat, reporter)
+	}
+
+	return withRetries(ctx, maxDownloadAttempts, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, entry.URL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return &httpStatusError{StatusCode: resp.StatusCode, URL: entry.URL}
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status %s downloading %s", resp.Status, entry.URL)
+		}
+
+		hasher := sha256.New()
+		body := io.TeeReader(reporter.wrap(entry.Name, resp.Body), hasher)
+
+		extractor := extractorFor(resp.Header.Get("Content-Type"), entry.URL)
+		if err := extractor.Extract(body, dirPath, opts); err != nil {
+			return err
+		}
+
+		dirHash, err := hashDir(dirPath)
+		if err != nil {
+			return fmt.Errorf("error hashing extracted directory %s: %w", dirPath, err)
+		}
+
+		mf.set(entry.Name, manifestEntry{
+			URL:           entry.URL,
+			ETag:          resp.Header.Get("ETag"),
+			LastModified:  resp.Header.Get("Last-Modified"),
+			ArchiveSHA256: fmt.Sprintf("%x", hasher.Sum(nil)),
+			DirHash:       dirHash,
+		})
+		return finishEntry(dirPath, entry.Name, concat, reporter)
+	})
+}
+
+// finishEntry feeds every .txt file found under dirPath into concat and reports the entry as
+// complete. It runs regardless of whether the entry was freshly extracted or found unchanged,
+// since the everything.txt output is rebuilt from the current on-disk state on every run.
+func finishEntry(dirPath, label string, concat *Concatenator, reporter *progressReporter) error {
+	for _, file := range findAllTxtFiles(dirPath) {
+		if err := concat.AddSource(file); err != nil {
+			return fmt.Errorf("error concatenating %s: %w", file, err)
+		}
+	}
+	reporter.complete(label)
 	return nil
 }
 
-// downloadAndUnzip handles the downloading of a ZIP file from the given URL and extracts its contents
-// into a directory within baseDir, named after the entry's "name".
-func downloadAndUnzip(url, name, baseDir string) error {
-	// Download the ZIP file.
-	resp, err := http.Get(url)
+// isNotModified issues a conditional HEAD request against url using prior's recorded validators
+// and reports whether the server confirmed the archive is unchanged (HTTP 304).
+func isNotModified(ctx context.Context, url string, prior manifestEntry) (bool, error) {
+	if prior.ETag == "" && prior.LastModified == "" {
+		return false, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return false, err
+	}
+	if prior.ETag != "" {
+		req.Header.Set("If-None-Match", prior.ETag)
+	}
+	if prior.LastModified != "" {
+		req.Header.Set("If-Modified-Since", prior.LastModified)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("error downloading %s: %w", url, err)
+		return false, err
 	}
 	defer resp.Body.Close()
 
-	// Create a temporary file to store the ZIP archive.
+	return resp.StatusCode == http.StatusNotModified, nil
+}
+
+// looksLikeZip reports whether url should be handled as a ZIP archive: it returns false when url
+// names a tar-family archive, and true for everything else, including Chaos's default
+// extensionless/.zip URLs.
+func looksLikeZip(url string) bool {
+	lowerURL := strings.ToLower(url)
+	for _, suffix := range []string{".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tar"} {
+		if strings.HasSuffix(lowerURL, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// httpStatusError records a non-2xx response so callers can decide whether it's worth retrying.
+type httpStatusError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("server returned status %d for %s", e.StatusCode, e.URL)
+}
+
+// staleCacheError signals that a cache file was discarded because it no longer matched what the
+// server could resume from, and that the download should be retried from scratch.
+type staleCacheError struct {
+	URL string
+}
+
+func (e *staleCacheError) Error() string {
+	return fmt.Sprintf("cache file for %s was stale and has been discarded", e.URL)
+}
+
+// isRetryable reports whether err is transient and worth another attempt: a 5xx response, a
+// discarded stale cache file, or a network-level error.
+func isRetryable(err error) bool {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	var staleErr *staleCacheError
+	if errors.As(err, &staleErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// withRetries calls fn until it succeeds, a non-retryable error occurs, ctx is canceled, or
+// maxAttempts is exhausted, backing off between attempts.
+func withRetries(ctx context.Context, maxAttempts int, fn func() error) error {
+	var lastErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt*attempt) * 500 * time.Millisecond):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if !isRetryable(err) {
+			return err
+		}
+		lastErr = err
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", maxAttempts, lastErr)
+}
+
+// validators holds the cache-related response headers of a successful download, recorded in the
+// manifest so future runs can make conditional requests.
+type validators struct {
+	etag         string
+	lastModified string
+}
+
+// downloadToCache downloads url into cachePath, resuming from cachePath's existing size via an
+// HTTP Range request when a previous attempt (in this run or a prior one) left a partial file
+// behind, and retrying transient failures with backoff. It returns the final response's
+// cache validators.
+func downloadToCache(ctx context.Context, url, cachePath, label string, reporter *progressReporter) (validators, error) {
+	var v validators
+	err := withRetries(ctx, maxDownloadAttempts, func() error {
+		var offset int64
+		if fi, err := os.Stat(cachePath); err == nil {
+			offset = fi.Size()
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return &httpStatusError{StatusCode: resp.StatusCode, URL: url}
+		}
+
+		var out *os.File
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+			out, err = os.OpenFile(cachePath, os.O_WRONLY|os.O_APPEND, 0o644)
+		case http.StatusOK:
+			// The server doesn't support Range, or this is a fresh download; start over.
+			out, err = os.Create(cachePath)
+		case http.StatusRequestedRangeNotSatisfiable:
+			// offset is past what the server has, most likely because cachePath already holds
+			// the complete archive from a prior run (cache files aren't deleted after
+			// extraction) and this run reached downloadToCache without skipping via a
+			// conditional request. Discard it and retry the whole download from scratch.
+			if rmErr := os.Remove(cachePath); rmErr != nil && !os.IsNotExist(rmErr) {
+				return fmt.Errorf("error removing stale cache file %s: %w", cachePath, rmErr)
+			}
+			return &staleCacheError{URL: url}
+		default:
+			return fmt.Errorf("unexpected status %s downloading %s", resp.Status, url)
+		}
+		if err != nil {
+			return fmt.Errorf("error opening cache file %s: %w", cachePath, err)
+		}
+
+		_, err = io.Copy(out, reporter.wrap(label, resp.Body))
+		out.Close()
+		if err != nil {
+			return err
+		}
+
+		v = validators{etag: resp.Header.Get("ETag"), lastModified: resp.Header.Get("Last-Modified")}
+		return nil
+	})
+	return v, err
+}
+
+// sha256File returns the hex-encoded SHA-256 digest of the file at path.
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// hashDir computes the h1-style directory hash of root the way
+// golang.org/x/mod/sumdb/dirhash does: every regular file's path (relative to root, slash
+// separated) is sorted, each file is hashed with SHA-256, "<hex>  <path>\n" lines are written in
+// that order into a second SHA-256 hash, and the result is returned as "h1:<base64>".
+func hashDir(root string) (string, error) {
+	var files []string
+	if err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	}); err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, name := range files {
+		sum, err := sha256File(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s  %s\n", sum, name)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// dirMatchesManifest reports whether dirPath's current contents still hash to prior's recorded
+// DirHash, guarding both the conditional-request (304) and archive-hash skip paths against a
+// cache file or manifest record surviving the loss of its extracted directory.
+func dirMatchesManifest(dirPath string, prior manifestEntry) bool {
+	if prior.DirHash == "" {
+		return false
+	}
+	dirHash, err := hashDir(dirPath)
+	return err == nil && dirHash == prior.DirHash
+}
+
+// manifestEntry is a single record in manifest.json, tracking enough about the last successful
+// download and extraction of an entry to skip redundant work on future runs.
+type manifestEntry struct {
+	URL           string `json:"url"`
+	ETag          string `json:"etag,omitempty"`
+	LastModified  string `json:"last_modified,omitempty"`
+	ArchiveSHA256 string `json:"archive_sha256,omitempty"`
+	DirHash       string `json:"dir_hash,omitempty"`
+}
+
+// manifestFile is the on-disk shape of manifest.json, keyed by entry name.
+type manifestFile struct {
+	Entries map[string]manifestEntry `json:"entries"`
+}
+
+// manifest is the manifestFile loaded for a run, safe for concurrent use by the worker pool.
+type manifest struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]manifestEntry
+}
+
+// loadManifest reads manifest.json from path, returning an empty manifest if it doesn't exist yet.
+func loadManifest(path string) (*manifest, error) {
+	m := &manifest{path: path, entries: make(map[string]manifestEntry)}
+
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest %s: %w", path, err)
+	}
+
+	var mf manifestFile
+	if err := json.Unmarshal(data, &mf); err != nil {
+		return nil, fmt.Errorf("error decoding manifest %s: %w", path, err)
+	}
+	if mf.Entries != nil {
+		m.entries = mf.Entries
+	}
+	return m, nil
+}
+
+// get returns the recorded entry for name, if any.
+func (m *manifest) get(name string) (manifestEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[name]
+	return e, ok
+}
+
+// set records the entry for name.
+func (m *manifest) set(name string, e manifestEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[name] = e
+}
+
+// save writes the manifest back to its path.
+func (m *manifest) save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, err := json.MarshalIndent(manifestFile{Entries: m.entries}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding manifest: %w", err)
+	}
+	return ioutil.WriteFile(m.path, data, 0o644)
+}
+
+// Extractor unpacks an archive read from r into destDir, subject to opts' limits.
+type Extractor interface {
+	Extract(r io.Reader, destDir string, opts chaos.ExtractOptions) error
+}
+
+// extractorFor picks the Extractor matching contentType and/or the archive's file extension in
+// url, falling back to the ZIP extractor since that's Chaos's native format.
+func extractorFor(contentType, url string) Extractor {
+	lowerURL := strings.ToLower(url)
+
+	switch {
+	case strings.Contains(contentType, "gzip"), strings.HasSuffix(lowerURL, ".tar.gz"), strings.HasSuffix(lowerURL, ".tgz"):
+		return gzipTarExtractor{}
+	case strings.Contains(contentType, "bzip2"), strings.HasSuffix(lowerURL, ".tar.bz2"), strings.HasSuffix(lowerURL, ".tbz2"):
+		return bzip2TarExtractor{}
+	case strings.Contains(contentType, "x-tar"), strings.HasSuffix(lowerURL, ".tar"):
+		return tarExtractor{}
+	default:
+		return zipExtractor{}
+	}
+}
+
+// zipExtractor unpacks ZIP archives. ZIP's central directory requires random access, so the
+// stream is buffered to a temp file rather than read directly.
+type zipExtractor struct{}
+
+func (zipExtractor) Extract(r io.Reader, destDir string, opts chaos.ExtractOptions) error {
 	tempFile, err := ioutil.TempFile("", "*.zip")
 	if err != nil {
 		return fmt.Errorf("error creating temp file: %w", err)
 	}
 	defer os.Remove(tempFile.Name()) // Ensure the temporary file is deleted.
 
-	// Write the downloaded content to the temporary file.
-	if _, err = io.Copy(tempFile, resp.Body); err != nil {
+	if _, err = io.Copy(tempFile, r); err != nil {
 		tempFile.Close()
 		return fmt.Errorf("error writing to temp file: %w", err)
 	}
 	tempFile.Close()
 
-	// Create a directory for the entry.
-	dirPath := filepath.Join(baseDir, name)
-	if err := os.MkdirAll(dirPath, os.ModePerm); err != nil {
-		return fmt.Errorf("error creating directory %s: %w", dirPath, err)
+	return extractZipFile(tempFile.Name(), destDir, opts)
+}
+
+// extractZipFile unpacks the ZIP archive already written to zipPath, e.g. a cache file populated
+// by downloadToCache, using pkg/chaos's shared extraction routine so the CLI's Zip Slip guard and
+// size limits can never drift from the library's.
+func extractZipFile(zipPath, destDir string, opts chaos.ExtractOptions) error {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return fmt.Errorf("error opening zip file: %w", err)
 	}
+	defer zr.Close()
 
-	// Extract the ZIP file into the directory.
-	if err := unzipFile(tempFile.Name(), dirPath); err != nil {
-		return fmt.Errorf("error unzipping file: %w", err)
+	destFS, err := chaos.NewDirFS(destDir)
+	if err != nil {
+		return err
 	}
+	return chaos.ExtractZip(&zr.Reader, destFS, opts)
+}
 
-	return nil
+// tarExtractor unpacks plain (uncompressed) tar archives, streaming entries directly from r.
+type tarExtractor struct{}
+
+func (tarExtractor) Extract(r io.Reader, destDir string, opts chaos.ExtractOptions) error {
+	return extractTar(tar.NewReader(r), destDir, opts)
 }
 
-// unzipFile extracts the contents of the specified ZIP file into the destination directory.
-func unzipFile(zipFile, destDir string) error {
-	r, err := zip.OpenReader(zipFile)
+// gzipTarExtractor unpacks gzip-compressed tar archives (.tar.gz, .tgz), streaming entries as
+// they're decompressed.
+type gzipTarExtractor struct{}
+
+func (gzipTarExtractor) Extract(r io.Reader, destDir string, opts chaos.ExtractOptions) error {
+	gz, err := gzip.NewReader(r)
 	if err != nil {
-		return fmt.Errorf("error opening zip file: %w", err)
+		return fmt.Errorf("error opening gzip stream: %w", err)
 	}
-	defer r.Close()
+	defer gz.Close()
+	return extractTar(tar.NewReader(gz), destDir, opts)
+}
 
-	// Iterate through each file in the ZIP archive.
-	for _, f := range r.File {
-		fpath := filepath.Join(destDir, f.Name)
+// bzip2TarExtractor unpacks bzip2-compressed tar archives (.tar.bz2, .tbz2), streaming entries as
+// they're decompressed.
+type bzip2TarExtractor struct{}
+
+func (bzip2TarExtractor) Extract(r io.Reader, destDir string, opts chaos.ExtractOptions) error {
+	return extractTar(tar.NewReader(bzip2.NewReader(r)), destDir, opts)
+}
+
+// extractTar streams entries out of tr into destDir, applying the same Zip Slip, symlink, and
+// size-limit protections as zipExtractor.
+func extractTar(tr *tar.Reader, destDir string, opts chaos.ExtractOptions) error {
+	var totalSize int64
+	var fileCount int
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading tar entry: %w", err)
+		}
+
+		if hdr.Typeflag == tar.TypeSymlink || hdr.Typeflag == tar.TypeLink {
+			return fmt.Errorf("refusing to extract symlink entry %q", hdr.Name)
+		}
+
+		fileCount++
+		if opts.MaxFiles > 0 && fileCount > opts.MaxFiles {
+			return fmt.Errorf("archive contains more than %d entries, exceeding the limit", opts.MaxFiles)
+		}
 
-		// Create directories if necessary.
-		if f.FileInfo().IsDir() {
-			os.MkdirAll(fpath, os.ModePerm)
+		name, err := chaos.SafeEntryName(hdr.Name)
+		if err != nil {
+			return err
+		}
+		fpath := filepath.Join(destDir, name)
+
+		if hdr.Typeflag == tar.TypeDir {
+			if err := os.MkdirAll(fpath, os.ModePerm); err != nil {
+				return err
+			}
 			continue
 		}
 
-		// Ensure the parent directory exists.
+		if opts.MaxFileSize > 0 && hdr.Size > opts.MaxFileSize {
+			return fmt.Errorf("entry %q is %d bytes uncompressed, exceeding the per-file limit of %d", hdr.Name, hdr.Size, opts.MaxFileSize)
+		}
+		totalSize += hdr.Size
+		if opts.MaxTotalSize > 0 && totalSize > opts.MaxTotalSize {
+			return fmt.Errorf("archive exceeds the total uncompressed size limit of %d bytes", opts.MaxTotalSize)
+		}
+
 		if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
 			return err
 		}
 
-		// Extract the file.
-		outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
+		outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(hdr.Mode))
 		if err != nil {
 			return fmt.Errorf("error opening output file: %w", err)
 		}
 
-		rc, err := f.Open()
-		if err != nil {
-			outFile.Close()
-			return fmt.Errorf("error opening zip content: %w", err)
-		}
-
-		_, err = io.Copy(outFile, rc)
-
+		_, err = io.Copy(outFile, tr)
 		outFile.Close()
-		rc.Close()
 
 		if err != nil {
 			return fmt.Errorf("error writing to output file: %w", err)
@@ -157,41 +734,287 @@ func unzipFile(zipFile, destDir string) error {
 	return nil
 }
 
-// concatenateAllTxtFiles finds all .txt files within baseDir and concatenates their contents
-// into a single file named 'everything.txt', placed in the specified output directory.
-func concatenateAllTxtFiles(baseDir, outputDir string) error {
-	allTxtFiles := findAllTxtFiles(baseDir)
+// progressReporter tracks bytes downloaded per target and prints a structured status line as
+// each target finishes, including a rough ETA based on the average time per completed target.
+type progressReporter struct {
+	mu    sync.Mutex
+	bytes map[string]int64
+	total int
+	done  int
+	start time.Time
+}
+
+func newProgressReporter(total int) *progressReporter {
+	return &progressReporter{bytes: make(map[string]int64), total: total, start: time.Now()}
+}
+
+// wrap returns an io.Reader that tallies bytes read under label as they're consumed.
+func (p *progressReporter) wrap(label string, r io.Reader) io.Reader {
+	return &countingReader{r: r, onRead: func(n int) {
+		p.mu.Lock()
+		p.bytes[label] += int64(n)
+		p.mu.Unlock()
+	}}
+}
+
+// complete records label as finished and prints a progress line for it.
+func (p *progressReporter) complete(label string) {
+	p.mu.Lock()
+	p.done++
+	done, total, size := p.done, p.total, p.bytes[label]
+	elapsed := time.Since(p.start)
+	p.mu.Unlock()
+
+	eta := "unknown"
+	if done > 0 && done < total {
+		remaining := elapsed / time.Duration(done) * time.Duration(total-done)
+		eta = remaining.Round(time.Second).String()
+	}
+	fmt.Printf("[%d/%d] %s: %s downloaded (eta %s)\n", done, total, label, humanizeBytes(size), eta)
+}
+
+// countingReader wraps an io.Reader and invokes onRead with the number of bytes returned by each
+// successful Read call.
+type countingReader struct {
+	r      io.Reader
+	onRead func(n int)
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		c.onRead(n)
+	}
+	return n, err
+}
 
-	destPath := filepath.Join(outputDir, "everything.txt")
-	dest, err := os.Create(destPath)
+// humanizeBytes formats n bytes as a short human-readable size, e.g. "4.2 MB".
+func humanizeBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for size := n / unit; size >= unit; size /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+// ShardMode selects how Concatenator splits its output across files.
+type ShardMode int
+
+const (
+	// ShardNone writes everything to a single "everything.txt" file.
+	ShardNone ShardMode = iota
+	// ShardByTLD writes one file per top-level domain, e.g. "everything.com.txt".
+	ShardByTLD
+	// ShardBySize rotates to a new "everything.part-NNN.txt" file once ShardSizeBytes is exceeded.
+	ShardBySize
+)
+
+// ConcatenatorOptions configures a Concatenator.
+type ConcatenatorOptions struct {
+	// Compress gzips each output file, appending ".gz" to its name.
+	Compress bool
+	// Shard selects how output is split across files.
+	Shard ShardMode
+	// ShardSizeBytes is the rotation threshold used when Shard is ShardBySize. Defaults to 256 MiB
+	// when zero.
+	ShardSizeBytes int64
+}
+
+// Concatenator streams deduplicated lines from many source files into one or more output files
+// named "everything[.shard].txt[.gz]" in outputDir, dropping lines it has already seen across
+// every AddSource call. It's safe to drive incrementally, e.g. once per target as extraction
+// finishes, rather than as a single pass over a fully populated tree.
+type Concatenator struct {
+	outputDir string
+	opts      ConcatenatorOptions
+
+	mu       sync.Mutex
+	seen     map[string]struct{}
+	tldFiles map[string]io.WriteCloser
+
+	shardIndex int
+	shardSize  int64
+	shardFile  io.WriteCloser
+}
+
+// NewConcatenator creates a Concatenator writing into outputDir.
+func NewConcatenator(outputDir string, opts ConcatenatorOptions) (*Concatenator, error) {
+	if opts.ShardSizeBytes <= 0 {
+		opts.ShardSizeBytes = 256 << 20
+	}
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("error creating output directory %s: %w", outputDir, err)
+	}
+	return &Concatenator{
+		outputDir: outputDir,
+		opts:      opts,
+		seen:      make(map[string]struct{}),
+		tldFiles:  make(map[string]io.WriteCloser),
+	}, nil
+}
+
+// AddSource streams path line by line, writing every non-empty line not seen before in this
+// Concatenator's lifetime to the appropriate output shard.
+func (c *Concatenator) AddSource(path string) error {
+	src, err := os.Open(path)
 	if err != nil {
-		return fmt.Errorf("error creating %s: %w", destPath, err)
+		return err
 	}
-	defer dest.Close()
+	defer src.Close()
 
-	// Concatenate the contents of each .txt file into 'everything.txt'.
-	for _, file := range allTxtFiles {
-		src, err := os.Open(file)
-		if err != nil {
-			log.Printf("Failed to open %s for reading: %v", file, err)
+	scanner := bufio.NewScanner(src)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
 			continue
 		}
+		if err := c.writeLine(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
 
-		if _, err = io.Copy(dest, src); err != nil {
-			src.Close()
-			log.Printf("Failed to copy %s to %s: %v", file, destPath, err)
-			continue
+// writeLine routes a deduplicated line to its shard, or drops it silently if already seen.
+func (c *Concatenator) writeLine(line string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, dup := c.seen[line]; dup {
+		return nil
+	}
+	c.seen[line] = struct{}{}
+
+	w, err := c.writerForLocked(line)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, line)
+	return err
+}
+
+// writerForLocked returns the writer line should be written to, creating or rotating shards as
+// needed. c.mu must be held.
+func (c *Concatenator) writerForLocked(line string) (io.Writer, error) {
+	if c.opts.Shard == ShardByTLD {
+		tld := tldOf(line)
+		if w, ok := c.tldFiles[tld]; ok {
+			return w, nil
 		}
-		src.Close()
+		w, err := c.newWriter(tld)
+		if err != nil {
+			return nil, err
+		}
+		c.tldFiles[tld] = w
+		return w, nil
+	}
 
-		// Write a newline after each file's content.
-		if _, err = dest.WriteString("\n"); err != nil {
-			log.Printf("Failed to write newline after %s: %v", file, err)
+	lineSize := int64(len(line) + 1)
+	if c.opts.Shard == ShardBySize && c.shardFile != nil && c.shardSize+lineSize > c.opts.ShardSizeBytes {
+		if err := c.shardFile.Close(); err != nil {
+			return nil, fmt.Errorf("error closing shard: %w", err)
 		}
+		c.shardFile = nil
+		c.shardIndex++
+		c.shardSize = 0
 	}
 
-	fmt.Printf("Successfully created %s with all .txt file content.\n", destPath)
-	return nil
+	if c.shardFile == nil {
+		suffix := ""
+		if c.opts.Shard == ShardBySize {
+			suffix = fmt.Sprintf("part-%03d", c.shardIndex)
+		}
+		w, err := c.newWriter(suffix)
+		if err != nil {
+			return nil, err
+		}
+		c.shardFile = w
+	}
+	c.shardSize += lineSize
+	return c.shardFile, nil
+}
+
+// newWriter opens a new output file named "everything[.suffix].txt[.gz]", gzip-wrapped when
+// Compress is set.
+func (c *Concatenator) newWriter(suffix string) (io.WriteCloser, error) {
+	name := "everything"
+	if suffix != "" {
+		name += "." + suffix
+	}
+	name += ".txt"
+	if c.opts.Compress {
+		name += ".gz"
+	}
+
+	f, err := os.Create(filepath.Join(c.outputDir, name))
+	if err != nil {
+		return nil, fmt.Errorf("error creating %s: %w", name, err)
+	}
+	if !c.opts.Compress {
+		return f, nil
+	}
+	return &gzipFile{gz: gzip.NewWriter(f), f: f}, nil
+}
+
+// Close flushes and closes every output file the Concatenator has opened.
+func (c *Concatenator) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var firstErr error
+	if c.shardFile != nil {
+		if err := c.shardFile.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, w := range c.tldFiles {
+		if err := w.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// gzipFile wraps an *os.File with a gzip.Writer, closing both in the right order.
+type gzipFile struct {
+	gz *gzip.Writer
+	f  *os.File
+}
+
+func (g *gzipFile) Write(p []byte) (int, error) {
+	return g.gz.Write(p)
+}
+
+func (g *gzipFile) Close() error {
+	if err := g.gz.Close(); err != nil {
+		g.f.Close()
+		return err
+	}
+	return g.f.Close()
+}
+
+// tldOf returns the lowercased top-level domain of a subdomain line, or "misc" if line doesn't
+// look like a plain domain name.
+func tldOf(line string) string {
+	line = strings.TrimSpace(line)
+	idx := strings.LastIndexByte(line, '.')
+	if idx == -1 || idx == len(line)-1 {
+		return "misc"
+	}
+	tld := strings.ToLower(line[idx+1:])
+	for _, r := range tld {
+		if r < 'a' || r > 'z' {
+			return "misc"
+		}
+	}
+	return tld
 }
 
 // findAllTxtFiles recursively finds all .txt files starting from the root directory and returns their paths.
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tonytsep/chaos_downloader/pkg/chaos"
+)
+
+// buildZipBytes returns a ZIP archive containing files, keyed by entry name.
+func buildZipBytes(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Create(%q): %v", name, err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("Write(%q): %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zw.Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// buildTarBytes returns an uncompressed tar archive containing files, keyed by entry name.
+func buildTarBytes(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("WriteHeader(%q): %v", name, err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("Write(%q): %v", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tw.Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestZipExtractorRejectsZipSlip(t *testing.T) {
+	for _, name := range []string{"../escaped.txt", "/etc/passwd", "a/../../b"} {
+		t.Run(name, func(t *testing.T) {
+			data := buildZipBytes(t, map[string]string{name: "payload"})
+			err := (zipExtractor{}).Extract(bytes.NewReader(data), t.TempDir(), chaos.ExtractOptions{})
+			if err == nil {
+				t.Fatalf("Extract(%q) succeeded, want zip slip error", name)
+			}
+		})
+	}
+}
+
+func TestZipExtractorEnforcesLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		opts chaos.ExtractOptions
+	}{
+		{name: "max file size", opts: chaos.ExtractOptions{MaxFileSize: 4}},
+		{name: "max total size", opts: chaos.ExtractOptions{MaxTotalSize: 4}},
+		{name: "max files", opts: chaos.ExtractOptions{MaxFiles: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := buildZipBytes(t, map[string]string{"a.txt": "hello", "b.txt": "world"})
+			err := (zipExtractor{}).Extract(bytes.NewReader(data), t.TempDir(), tt.opts)
+			if err == nil {
+				t.Fatalf("Extract with %+v succeeded, want a limit error", tt.opts)
+			}
+		})
+	}
+}
+
+func TestZipExtractorWritesFiles(t *testing.T) {
+	data := buildZipBytes(t, map[string]string{"sub/domain.txt": "hello\n"})
+	dir := t.TempDir()
+	if err := (zipExtractor{}).Extract(bytes.NewReader(data), dir, chaos.DefaultExtractOptions()); err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "sub", "domain.txt"))
+	if err != nil {
+		t.Fatalf("reading extracted file: %v", err)
+	}
+	if string(got) != "hello\n" {
+		t.Fatalf("extracted content = %q, want %q", got, "hello\n")
+	}
+}
+
+func TestTarExtractorRejectsZipSlip(t *testing.T) {
+	for _, name := range []string{"../escaped.txt", "/etc/passwd", "a/../../b"} {
+		t.Run(name, func(t *testing.T) {
+			data := buildTarBytes(t, map[string]string{name: "payload"})
+			err := (tarExtractor{}).Extract(bytes.NewReader(data), t.TempDir(), chaos.ExtractOptions{})
+			if err == nil {
+				t.Fatalf("Extract(%q) succeeded, want zip slip error", name)
+			}
+		})
+	}
+}
+
+func TestTarExtractorEnforcesLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		opts chaos.ExtractOptions
+	}{
+		{name: "max file size", opts: chaos.ExtractOptions{MaxFileSize: 4}},
+		{name: "max total size", opts: chaos.ExtractOptions{MaxTotalSize: 4}},
+		{name: "max files", opts: chaos.ExtractOptions{MaxFiles: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := buildTarBytes(t, map[string]string{"a.txt": "hello", "b.txt": "world"})
+			err := (tarExtractor{}).Extract(bytes.NewReader(data), t.TempDir(), tt.opts)
+			if err == nil {
+				t.Fatalf("Extract with %+v succeeded, want a limit error", tt.opts)
+			}
+		})
+	}
+}
+
+func TestTarExtractorWritesFiles(t *testing.T) {
+	data := buildTarBytes(t, map[string]string{"sub/domain.txt": "hello\n"})
+	dir := t.TempDir()
+	if err := (tarExtractor{}).Extract(bytes.NewReader(data), dir, chaos.DefaultExtractOptions()); err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "sub", "domain.txt"))
+	if err != nil {
+		t.Fatalf("reading extracted file: %v", err)
+	}
+	if string(got) != "hello\n" {
+		t.Fatalf("extracted content = %q, want %q", got, "hello\n")
+	}
+}
+
+func TestGzipTarExtractorWritesFiles(t *testing.T) {
+	var gzBuf bytes.Buffer
+	gw := gzip.NewWriter(&gzBuf)
+	if _, err := gw.Write(buildTarBytes(t, map[string]string{"chaos.txt": "chaos\n"})); err != nil {
+		t.Fatalf("gzip write: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("gzip close: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := (gzipTarExtractor{}).Extract(bytes.NewReader(gzBuf.Bytes()), dir, chaos.DefaultExtractOptions()); err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "chaos.txt"))
+	if err != nil {
+		t.Fatalf("reading extracted file: %v", err)
+	}
+	if string(got) != "chaos\n" {
+		t.Fatalf("extracted content = %q, want %q", got, "chaos\n")
+	}
+}
+
+func TestBzip2TarExtractorRejectsZipSlip(t *testing.T) {
+	// compress/bzip2 is decompress-only in the standard library, so exercise the same
+	// extractTar guard bzip2TarExtractor delegates to, against a plain tar.Reader, rather than
+	// round-tripping through a bzip2 compressor.
+	data := buildTarBytes(t, map[string]string{"../escaped.txt": "payload"})
+	if err := extractTar(tar.NewReader(bytes.NewReader(data)), t.TempDir(), chaos.ExtractOptions{}); err == nil {
+		t.Fatal("extractTar succeeded, want zip slip error")
+	}
+}
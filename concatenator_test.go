@@ -0,0 +1,201 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestTldOf(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{line: "sub.example.com", want: "com"},
+		{line: "example.IO", want: "io"},
+		{line: "  spaced.net  ", want: "net"},
+		{line: "no-dot", want: "misc"},
+		{line: "trailing.", want: "misc"},
+		{line: "1.2.3.4", want: "misc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			if got := tldOf(tt.line); got != tt.want {
+				t.Fatalf("tldOf(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+// writeSource creates a file under dir containing lines, one per line.
+func writeSource(t *testing.T, dir, name string, lines []string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	content := ""
+	for _, line := range lines {
+		content += line + "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile(%s): %v", path, err)
+	}
+	return path
+}
+
+// readOutputFiles returns the contents of every non-directory entry in dir, keyed by name.
+func readOutputFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir(%s): %v", dir, err)
+	}
+	out := make(map[string]string)
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatalf("ReadFile(%s): %v", e.Name(), err)
+		}
+		out[e.Name()] = string(data)
+	}
+	return out
+}
+
+func TestConcatenatorDedupesAcrossSources(t *testing.T) {
+	srcDir, outDir := t.TempDir(), t.TempDir()
+	concat, err := NewConcatenator(outDir, ConcatenatorOptions{})
+	if err != nil {
+		t.Fatalf("NewConcatenator: %v", err)
+	}
+
+	a := writeSource(t, srcDir, "a.txt", []string{"one.example.com", "two.example.com"})
+	b := writeSource(t, srcDir, "b.txt", []string{"two.example.com", "three.example.com", ""})
+
+	if err := concat.AddSource(a); err != nil {
+		t.Fatalf("AddSource(a): %v", err)
+	}
+	if err := concat.AddSource(b); err != nil {
+		t.Fatalf("AddSource(b): %v", err)
+	}
+	if err := concat.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	out := readOutputFiles(t, outDir)
+	data, ok := out["everything.txt"]
+	if !ok {
+		t.Fatalf("no everything.txt produced, got %v", out)
+	}
+
+	var lines []string
+	for _, l := range splitNonEmptyLines(data) {
+		lines = append(lines, l)
+	}
+	sort.Strings(lines)
+	want := []string{"one.example.com", "three.example.com", "two.example.com"}
+	if len(lines) != len(want) {
+		t.Fatalf("lines = %v, want %v", lines, want)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Fatalf("lines = %v, want %v", lines, want)
+		}
+	}
+}
+
+func splitNonEmptyLines(s string) []string {
+	var lines []string
+	start := 0
+	for i, r := range s {
+		if r == '\n' {
+			if line := s[start:i]; line != "" {
+				lines = append(lines, line)
+			}
+			start = i + 1
+		}
+	}
+	return lines
+}
+
+func TestConcatenatorShardByTLD(t *testing.T) {
+	srcDir, outDir := t.TempDir(), t.TempDir()
+	concat, err := NewConcatenator(outDir, ConcatenatorOptions{Shard: ShardByTLD})
+	if err != nil {
+		t.Fatalf("NewConcatenator: %v", err)
+	}
+
+	src := writeSource(t, srcDir, "a.txt", []string{"one.example.com", "two.example.io", "weird"})
+	if err := concat.AddSource(src); err != nil {
+		t.Fatalf("AddSource: %v", err)
+	}
+	if err := concat.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	out := readOutputFiles(t, outDir)
+	for _, name := range []string{"everything.com.txt", "everything.io.txt", "everything.misc.txt"} {
+		if _, ok := out[name]; !ok {
+			t.Fatalf("missing shard file %s, got %v", name, out)
+		}
+	}
+}
+
+func TestConcatenatorShardBySizeRotates(t *testing.T) {
+	srcDir, outDir := t.TempDir(), t.TempDir()
+	concat, err := NewConcatenator(outDir, ConcatenatorOptions{Shard: ShardBySize, ShardSizeBytes: 10})
+	if err != nil {
+		t.Fatalf("NewConcatenator: %v", err)
+	}
+
+	src := writeSource(t, srcDir, "a.txt", []string{"aaaaaaaaaa", "bbbbbbbbbb", "cccccccccc"})
+	if err := concat.AddSource(src); err != nil {
+		t.Fatalf("AddSource: %v", err)
+	}
+	if err := concat.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	out := readOutputFiles(t, outDir)
+	for _, name := range []string{"everything.part-000.txt", "everything.part-001.txt", "everything.part-002.txt"} {
+		if _, ok := out[name]; !ok {
+			t.Fatalf("missing shard file %s, got %v", name, out)
+		}
+	}
+}
+
+func TestConcatenatorCompress(t *testing.T) {
+	srcDir, outDir := t.TempDir(), t.TempDir()
+	concat, err := NewConcatenator(outDir, ConcatenatorOptions{Compress: true})
+	if err != nil {
+		t.Fatalf("NewConcatenator: %v", err)
+	}
+
+	src := writeSource(t, srcDir, "a.txt", []string{"chaos.projectdiscovery.io"})
+	if err := concat.AddSource(src); err != nil {
+		t.Fatalf("AddSource: %v", err)
+	}
+	if err := concat.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	f, err := os.Open(filepath.Join(outDir, "everything.txt.gz"))
+	if err != nil {
+		t.Fatalf("opening gzip output: %v", err)
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	defer gr.Close()
+
+	data, err := io.ReadAll(gr)
+	if err != nil {
+		t.Fatalf("reading gzip stream (missing trailer?): %v", err)
+	}
+	if string(data) != "chaos.projectdiscovery.io\n" {
+		t.Fatalf("decompressed content = %q", data)
+	}
+}
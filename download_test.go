@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeNetError implements net.Error for testing isRetryable's network-error branch.
+type fakeNetError struct{}
+
+func (fakeNetError) Error() string   { return "fake net error" }
+func (fakeNetError) Timeout() bool   { return true }
+func (fakeNetError) Temporary() bool { return true }
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "5xx status", err: &httpStatusError{StatusCode: http.StatusServiceUnavailable}, want: true},
+		{name: "4xx status", err: &httpStatusError{StatusCode: http.StatusNotFound}, want: false},
+		{name: "stale cache", err: &staleCacheError{URL: "http://example.test"}, want: true},
+		{name: "network error", err: fakeNetError{}, want: true},
+		{name: "generic error", err: errors.New("boom"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.want {
+				t.Fatalf("isRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithRetriesSucceedsAfterTransientErrors(t *testing.T) {
+	attempts := 0
+	err := withRetries(context.Background(), 3, func() error {
+		attempts++
+		if attempts < 3 {
+			return &httpStatusError{StatusCode: http.StatusServiceUnavailable}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("withRetries: %v", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("attempts = %d, want 3", attempts)
+	}
+}
+
+func TestWithRetriesStopsOnNonRetryableError(t *testing.T) {
+	wantErr := errors.New("boom")
+	attempts := 0
+	err := withRetries(context.Background(), 5, func() error {
+		attempts++
+		return wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("withRetries error = %v, want %v", err, wantErr)
+	}
+	if attempts != 1 {
+		t.Fatalf("attempts = %d, want 1", attempts)
+	}
+}
+
+func TestWithRetriesGivesUpAfterMaxAttempts(t *testing.T) {
+	attempts := 0
+	err := withRetries(context.Background(), 2, func() error {
+		attempts++
+		return &httpStatusError{StatusCode: http.StatusServiceUnavailable}
+	})
+	if err == nil {
+		t.Fatal("withRetries succeeded, want an error after exhausting attempts")
+	}
+	if attempts != 2 {
+		t.Fatalf("attempts = %d, want 2", attempts)
+	}
+}
+
+func TestWithRetriesRespectsCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	attempts := 0
+	err := withRetries(ctx, 5, func() error {
+		attempts++
+		return &httpStatusError{StatusCode: http.StatusServiceUnavailable}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("withRetries error = %v, want context.Canceled", err)
+	}
+	if attempts != 1 {
+		t.Fatalf("attempts = %d, want 1 (no retry after cancellation)", attempts)
+	}
+}
+
+func TestDownloadToCacheFreshDownload(t *testing.T) {
+	body := []byte("hello world")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	cachePath := filepath.Join(t.TempDir(), "archive.zip")
+	if _, err := downloadToCache(context.Background(), srv.URL, cachePath, "entry", newProgressReporter(1)); err != nil {
+		t.Fatalf("downloadToCache: %v", err)
+	}
+
+	got, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatalf("reading cache file: %v", err)
+	}
+	if string(got) != string(body) {
+		t.Fatalf("cache contents = %q, want %q", got, body)
+	}
+}
+
+func TestDownloadToCacheResumesPartialDownload(t *testing.T) {
+	body := []byte("0123456789")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rangeHeader := r.Header.Get("Range")
+		if rangeHeader == "" {
+			w.Write(body)
+			return
+		}
+		var offset int64
+		if _, err := fmt.Sscanf(rangeHeader, "bytes=%d-", &offset); err != nil {
+			http.Error(w, "bad range", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(body[offset:])
+	}))
+	defer srv.Close()
+
+	cachePath := filepath.Join(t.TempDir(), "archive.zip")
+	if err := os.WriteFile(cachePath, body[:4], 0o644); err != nil {
+		t.Fatalf("seeding partial cache file: %v", err)
+	}
+
+	if _, err := downloadToCache(context.Background(), srv.URL, cachePath, "entry", newProgressReporter(1)); err != nil {
+		t.Fatalf("downloadToCache: %v", err)
+	}
+
+	got, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatalf("reading cache file: %v", err)
+	}
+	if string(got) != string(body) {
+		t.Fatalf("cache contents = %q, want %q", got, body)
+	}
+}
+
+func TestDownloadToCacheDiscardsStaleCacheOn416(t *testing.T) {
+	body := []byte("archive contents")
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Range") != "" {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	cachePath := filepath.Join(t.TempDir(), "archive.zip")
+	// Simulate a cache file left behind from a run that completed and was extracted.
+	if err := os.WriteFile(cachePath, body, 0o644); err != nil {
+		t.Fatalf("seeding complete cache file: %v", err)
+	}
+
+	if _, err := downloadToCache(context.Background(), srv.URL, cachePath, "entry", newProgressReporter(1)); err != nil {
+		t.Fatalf("downloadToCache: %v", err)
+	}
+
+	got, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatalf("reading cache file: %v", err)
+	}
+	if string(got) != string(body) {
+		t.Fatalf("cache contents = %q, want %q", got, body)
+	}
+	if requests < 2 {
+		t.Fatalf("requests = %d, want at least 2 (416 then a fresh retry)", requests)
+	}
+}
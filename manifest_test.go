@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashDirIsDeterministicAndContentSensitive(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), os.ModePerm); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("world"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	h1, err := hashDir(dir)
+	if err != nil {
+		t.Fatalf("hashDir: %v", err)
+	}
+	h2, err := hashDir(dir)
+	if err != nil {
+		t.Fatalf("hashDir: %v", err)
+	}
+	if h1 != h2 {
+		t.Fatalf("hashDir not deterministic: %q != %q", h1, h2)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("changed"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	h3, err := hashDir(dir)
+	if err != nil {
+		t.Fatalf("hashDir: %v", err)
+	}
+	if h3 == h1 {
+		t.Fatal("hashDir didn't change after a file's contents changed")
+	}
+}
+
+func TestDirMatchesManifest(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	dirHash, err := hashDir(dir)
+	if err != nil {
+		t.Fatalf("hashDir: %v", err)
+	}
+
+	if !dirMatchesManifest(dir, manifestEntry{DirHash: dirHash}) {
+		t.Fatal("dirMatchesManifest = false, want true for a matching hash")
+	}
+	if dirMatchesManifest(dir, manifestEntry{DirHash: "h1:deadbeef"}) {
+		t.Fatal("dirMatchesManifest = true, want false for a stale hash")
+	}
+	if dirMatchesManifest(dir, manifestEntry{}) {
+		t.Fatal("dirMatchesManifest = true, want false when no DirHash was ever recorded")
+	}
+	if dirMatchesManifest(filepath.Join(dir, "missing"), manifestEntry{DirHash: dirHash}) {
+		t.Fatal("dirMatchesManifest = true, want false for a directory that no longer exists")
+	}
+}
+
+func TestManifestLoadSaveRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+
+	mf, err := loadManifest(path)
+	if err != nil {
+		t.Fatalf("loadManifest (missing file): %v", err)
+	}
+	if _, ok := mf.get("example.com"); ok {
+		t.Fatal("get on an empty manifest returned an entry")
+	}
+
+	mf.set("example.com", manifestEntry{URL: "https://example.com/archive.zip", ArchiveSHA256: "abc", DirHash: "h1:xyz"})
+	if err := mf.save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	reloaded, err := loadManifest(path)
+	if err != nil {
+		t.Fatalf("loadManifest (reload): %v", err)
+	}
+	entry, ok := reloaded.get("example.com")
+	if !ok {
+		t.Fatal("reloaded manifest is missing the saved entry")
+	}
+	if entry.ArchiveSHA256 != "abc" || entry.DirHash != "h1:xyz" {
+		t.Fatalf("reloaded entry = %+v, want ArchiveSHA256=abc DirHash=h1:xyz", entry)
+	}
+}
+
+func TestIsNotModified(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	notModified, err := isNotModified(context.Background(), srv.URL, manifestEntry{ETag: `"v1"`})
+	if err != nil {
+		t.Fatalf("isNotModified: %v", err)
+	}
+	if !notModified {
+		t.Fatal("isNotModified = false, want true for a matching ETag")
+	}
+
+	notModified, err = isNotModified(context.Background(), srv.URL, manifestEntry{ETag: `"stale"`})
+	if err != nil {
+		t.Fatalf("isNotModified: %v", err)
+	}
+	if notModified {
+		t.Fatal("isNotModified = true, want false for a stale ETag")
+	}
+}
+
+func TestIsNotModifiedWithoutPriorValidatorsSkipsTheRequest(t *testing.T) {
+	// Point at an address nothing is listening on; if isNotModified made a request despite
+	// having no validators to send, it would fail to connect instead of returning (false, nil).
+	notModified, err := isNotModified(context.Background(), "http://127.0.0.1:1", manifestEntry{})
+	if err != nil {
+		t.Fatalf("isNotModified: %v", err)
+	}
+	if notModified {
+		t.Fatal("isNotModified = true, want false with no prior validators")
+	}
+}
@@ -0,0 +1,300 @@
+// Package chaos provides a small client library for Project Discovery's Chaos dataset: fetching
+// the JSON index and downloading or extracting the ZIP archive behind each entry.
+package chaos
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultIndexURL is the Chaos JSON index fetched by a zero-value Client.
+const DefaultIndexURL = "https://chaos-data.projectdiscovery.io/index.json"
+
+// Client fetches the Chaos index and hands out Entry values that know how to download
+// themselves. The zero value uses DefaultIndexURL and http.DefaultClient.
+type Client struct {
+	// HTTPClient is used for all requests. http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+	// IndexURL overrides DefaultIndexURL when set.
+	IndexURL string
+}
+
+// NewClient returns a Client configured with DefaultIndexURL and http.DefaultClient.
+func NewClient() *Client {
+	return &Client{}
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (c *Client) indexURL() string {
+	if c.IndexURL != "" {
+		return c.IndexURL
+	}
+	return DefaultIndexURL
+}
+
+// Index is the decoded Chaos JSON index.
+type Index struct {
+	Entries []Entry
+}
+
+// FetchIndex downloads and decodes the Chaos JSON index, returning one Entry per target.
+func (c *Client) FetchIndex(ctx context.Context) (*Index, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.indexURL(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error building request for JSON index: %w", err)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching JSON index: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var raw []struct {
+		Name string `json:"name"`
+		URL  string `json:"URL"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("error decoding JSON index: %w", err)
+	}
+
+	entries := make([]Entry, len(raw))
+	for i, r := range raw {
+		entries[i] = Entry{Name: r.Name, URL: r.URL, client: c}
+	}
+	return &Index{Entries: entries}, nil
+}
+
+// ExtractOptions bounds how much data an Extract call is allowed to produce, guarding against zip
+// bombs and other hostile archives.
+type ExtractOptions struct {
+	// MaxFileSize is the largest uncompressed size allowed for a single entry, in bytes.
+	// Zero means unlimited.
+	MaxFileSize int64
+	// MaxTotalSize is the largest combined uncompressed size allowed for the whole archive, in
+	// bytes. Zero means unlimited.
+	MaxTotalSize int64
+	// MaxFiles is the largest number of entries allowed in the archive. Zero means unlimited.
+	MaxFiles int
+}
+
+// DefaultExtractOptions returns the limits applied when the caller does not configure its own,
+// sized generously for Chaos's subdomain archives while still rejecting pathological zip bombs.
+func DefaultExtractOptions() ExtractOptions {
+	return ExtractOptions{
+		MaxFileSize:  1 << 30, // 1 GiB per file
+		MaxTotalSize: 8 << 30, // 8 GiB per archive
+		MaxFiles:     100000,
+	}
+}
+
+// Entry is one target in the Chaos index.
+type Entry struct {
+	// Name is the target's identifier in the index, e.g. a domain name.
+	Name string
+	// URL is the location of the target's ZIP archive.
+	URL string
+
+	client *Client
+}
+
+func (e Entry) httpClient() *http.Client {
+	if e.client != nil {
+		return e.client.httpClient()
+	}
+	return http.DefaultClient
+}
+
+// Zip downloads Entry's archive and writes its raw bytes to dst, without touching disk. This is
+// the building block both Extract and callers with their own destination (an S3 upload, a
+// zip.NewReader over a bytes.Buffer, ...) use.
+func (e Entry) Zip(dst io.Writer) error {
+	req, err := http.NewRequest(http.MethodGet, e.URL, nil)
+	if err != nil {
+		return fmt.Errorf("error building request for %s: %w", e.URL, err)
+	}
+
+	resp, err := e.httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("error downloading %s: %w", e.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s downloading %s", resp.Status, e.URL)
+	}
+
+	if _, err := io.Copy(dst, resp.Body); err != nil {
+		return fmt.Errorf("error writing %s: %w", e.URL, err)
+	}
+	return nil
+}
+
+// Extract downloads Entry's archive and unpacks it into destFS, which must also implement
+// WriteFS, subject to opts' limits. Accepting the narrower fs.FS lets callers pass any
+// destination, with the write capability checked at the point of use rather than baked into the
+// signature.
+func (e Entry) Extract(destFS fs.FS, opts ExtractOptions) error {
+	w, ok := destFS.(WriteFS)
+	if !ok {
+		return fmt.Errorf("chaos: destFS of type %T does not implement chaos.WriteFS", destFS)
+	}
+
+	var buf bytes.Buffer
+	if err := e.Zip(&buf); err != nil {
+		return err
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		return fmt.Errorf("error opening zip for %s: %w", e.Name, err)
+	}
+
+	if err := ExtractZip(zr, w, opts); err != nil {
+		return fmt.Errorf("error extracting %s: %w", e.Name, err)
+	}
+	return nil
+}
+
+// ExtractZip unpacks every entry of zr into destFS, subject to opts' limits, applying the same
+// Zip Slip and symlink guards as Entry.Extract. It's exported so callers that already have a
+// *zip.Reader over a locally cached archive (e.g. the CLI's resumable download cache) share this
+// package's extraction logic instead of reimplementing it.
+func ExtractZip(zr *zip.Reader, destFS WriteFS, opts ExtractOptions) error {
+	if opts.MaxFiles > 0 && len(zr.File) > opts.MaxFiles {
+		return fmt.Errorf("archive contains %d entries, exceeding the limit of %d", len(zr.File), opts.MaxFiles)
+	}
+
+	var totalSize int64
+	for _, f := range zr.File {
+		if !f.FileInfo().IsDir() {
+			if opts.MaxFileSize > 0 && int64(f.UncompressedSize64) > opts.MaxFileSize {
+				return fmt.Errorf("entry %q is %d bytes uncompressed, exceeding the per-file limit of %d", f.Name, f.UncompressedSize64, opts.MaxFileSize)
+			}
+			totalSize += int64(f.UncompressedSize64)
+			if opts.MaxTotalSize > 0 && totalSize > opts.MaxTotalSize {
+				return fmt.Errorf("archive exceeds the total uncompressed size limit of %d bytes", opts.MaxTotalSize)
+			}
+		}
+		if err := extractZipFileTo(destFS, f); err != nil {
+			return fmt.Errorf("error extracting %s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// WriteFS is an fs.FS that can also be written to, so Entry.Extract can unpack an archive into
+// either a real directory (see DirFS) or an in-memory fake for tests.
+type WriteFS interface {
+	fs.FS
+	// MkdirAll creates dir and any missing parents.
+	MkdirAll(dir string, perm fs.FileMode) error
+	// Create creates (or truncates) name for writing.
+	Create(name string) (io.WriteCloser, error)
+}
+
+// extractZipFileTo writes a single zip.File into w, rejecting the same Zip Slip and symlink
+// hazards as the CLI's own extractors.
+func extractZipFileTo(w WriteFS, f *zip.File) error {
+	name, err := SafeEntryName(f.Name)
+	if err != nil {
+		return err
+	}
+	if f.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("refusing to extract symlink entry %q", f.Name)
+	}
+
+	if f.FileInfo().IsDir() {
+		return w.MkdirAll(name, os.ModePerm)
+	}
+
+	if dir := filepath.Dir(name); dir != "." {
+		if err := w.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("error opening zip content: %w", err)
+	}
+	defer rc.Close()
+
+	out, err := w.Create(name)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %w", name, err)
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, rc)
+	return err
+}
+
+// ErrZipSlip is returned by SafeEntryName (and, wrapped, by ExtractZip) when an archive entry's
+// name would escape its destination directory, e.g. via a "../" or absolute path, letting callers
+// distinguish a Zip Slip rejection from other extraction errors with errors.As.
+type ErrZipSlip struct {
+	// Entry is the offending archive entry name.
+	Entry string
+}
+
+func (e *ErrZipSlip) Error() string {
+	return fmt.Sprintf("chaos: zip slip: entry %q escapes its destination", e.Entry)
+}
+
+// SafeEntryName cleans an archive entry name and rejects absolute paths or "../" escapes,
+// returning a *ErrZipSlip on rejection. It's the Zip Slip guard shared by every extractor in
+// this package and by the CLI's tar-family extractors, which can't go through WriteFS since they
+// stream straight to disk.
+func SafeEntryName(entryName string) (string, error) {
+	if filepath.IsAbs(entryName) {
+		return "", &ErrZipSlip{Entry: entryName}
+	}
+	clean := filepath.Clean(entryName)
+	if clean == ".." || strings.HasPrefix(clean, ".."+string(os.PathSeparator)) {
+		return "", &ErrZipSlip{Entry: entryName}
+	}
+	return clean, nil
+}
+
+// DirFS is a WriteFS backed by a real directory, analogous to os.DirFS but with the write methods
+// Entry.Extract needs.
+type DirFS string
+
+// NewDirFS returns a WriteFS rooted at dir, creating it if necessary.
+func NewDirFS(dir string) (DirFS, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", fmt.Errorf("error creating directory %s: %w", dir, err)
+	}
+	return DirFS(dir), nil
+}
+
+// Open implements fs.FS.
+func (d DirFS) Open(name string) (fs.File, error) {
+	return os.DirFS(string(d)).Open(name)
+}
+
+// MkdirAll implements WriteFS.
+func (d DirFS) MkdirAll(dir string, perm fs.FileMode) error {
+	return os.MkdirAll(filepath.Join(string(d), dir), perm)
+}
+
+// Create implements WriteFS.
+func (d DirFS) Create(name string) (io.WriteCloser, error) {
+	return os.Create(filepath.Join(string(d), name))
+}
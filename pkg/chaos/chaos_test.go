@@ -0,0 +1,171 @@
+package chaos
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSafeEntryName(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   string
+		wantErr bool
+	}{
+		{name: "plain file", entry: "sub/domain.txt"},
+		{name: "absolute path", entry: "/etc/passwd", wantErr: true},
+		{name: "parent escape", entry: "../../etc/passwd", wantErr: true},
+		{name: "embedded parent escape", entry: "a/../../b", wantErr: true},
+		{name: "dot", entry: "."},
+		{name: "redundant dot segment", entry: "a/./b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SafeEntryName(tt.entry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SafeEntryName(%q) error = %v, wantErr %v", tt.entry, err, tt.wantErr)
+			}
+			var zipSlip *ErrZipSlip
+			if errors.As(err, &zipSlip) != tt.wantErr {
+				t.Fatalf("SafeEntryName(%q) errors.As(*ErrZipSlip) = %v, want %v", tt.entry, err != nil && zipSlip != nil, tt.wantErr)
+			}
+		})
+	}
+}
+
+// buildZip returns a zip.Reader over an in-memory archive containing files, keyed by entry name.
+func buildZip(t *testing.T, files map[string]string) *zip.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Create(%q): %v", name, err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("Write(%q): %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zw.Close: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader: %v", err)
+	}
+	return zr
+}
+
+func TestExtractZipRejectsZipSlip(t *testing.T) {
+	tests := []string{"../escaped.txt", "/etc/passwd", "a/../../b"}
+	for _, name := range tests {
+		t.Run(name, func(t *testing.T) {
+			zr := buildZip(t, map[string]string{name: "payload"})
+			destFS, err := NewDirFS(t.TempDir())
+			if err != nil {
+				t.Fatalf("NewDirFS: %v", err)
+			}
+			if err := ExtractZip(zr, destFS, ExtractOptions{}); err == nil {
+				t.Fatalf("ExtractZip(%q) succeeded, want zip slip error", name)
+			}
+		})
+	}
+}
+
+func TestExtractZipEnforcesLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ExtractOptions
+	}{
+		{name: "max file size", opts: ExtractOptions{MaxFileSize: 4}},
+		{name: "max total size", opts: ExtractOptions{MaxTotalSize: 4}},
+		{name: "max files", opts: ExtractOptions{MaxFiles: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zr := buildZip(t, map[string]string{"a.txt": "hello", "b.txt": "world"})
+			destFS, err := NewDirFS(t.TempDir())
+			if err != nil {
+				t.Fatalf("NewDirFS: %v", err)
+			}
+			if err := ExtractZip(zr, destFS, tt.opts); err == nil {
+				t.Fatalf("ExtractZip with %+v succeeded, want a limit error", tt.opts)
+			}
+		})
+	}
+}
+
+func TestExtractZipWritesFiles(t *testing.T) {
+	zr := buildZip(t, map[string]string{"sub/domain.txt": "hello\n"})
+	dir := t.TempDir()
+	destFS, err := NewDirFS(dir)
+	if err != nil {
+		t.Fatalf("NewDirFS: %v", err)
+	}
+	if err := ExtractZip(zr, destFS, DefaultExtractOptions()); err != nil {
+		t.Fatalf("ExtractZip: %v", err)
+	}
+
+	got, err := readFile(destFS, "sub/domain.txt")
+	if err != nil {
+		t.Fatalf("reading extracted file: %v", err)
+	}
+	if got != "hello\n" {
+		t.Fatalf("extracted content = %q, want %q", got, "hello\n")
+	}
+}
+
+func readFile(destFS fs.FS, name string) (string, error) {
+	f, err := destFS.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	return string(data), err
+}
+
+func TestEntryExtract(t *testing.T) {
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	w, err := zw.Create("chaos.txt")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := w.Write([]byte("chaos.projectdiscovery.io\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zw.Close: %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write(zipBuf.Bytes())
+	}))
+	defer srv.Close()
+
+	e := Entry{Name: "chaos", URL: srv.URL}
+	dir := t.TempDir()
+	destFS, err := NewDirFS(dir)
+	if err != nil {
+		t.Fatalf("NewDirFS: %v", err)
+	}
+
+	if err := e.Extract(destFS, DefaultExtractOptions()); err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+
+	got, err := readFile(destFS, "chaos.txt")
+	if err != nil {
+		t.Fatalf("reading extracted file: %v", err)
+	}
+	if got != "chaos.projectdiscovery.io\n" {
+		t.Fatalf("extracted content = %q", got)
+	}
+}